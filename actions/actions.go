@@ -0,0 +1,124 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+// Package actions turns jpgextract into a general RAW ingest pipeline: a
+// Recipe (see recipe.go) declares an ordered list of Actions, and Run
+// streams each matched source file through every Action in turn using a
+// bounded worker pool. Built-in Actions are registered in this package's
+// init() functions (extract.go, rotate.go, resize.go, rename.go, upload.go,
+// index.go); a third-party Action can be added the same way Register is
+// called here.
+//
+// Unlike the one-shot --raws/--src-dirs/--dest-dir flow, a Recipe has no
+// concept of --cache-dir: Run does not consult or populate a cache.Store,
+// so re-running the same recipe always re-processes every file matched by
+// Src. The "index" Action (index.go) only appends a record of what Run
+// already processed; it is a log, not a lookup, and does not make a recipe
+// skip files it has indexed before. Give a recipe a narrower Src (or a
+// fresh dest-dir per run) if re-processing is a problem.
+package actions
+
+import (
+	"context"
+	"fmt"
+)
+
+// Config holds the pipeline-wide defaults a Recipe's actions run against.
+type Config struct {
+	DestDir string
+	Quality int
+}
+
+// File tracks one source RAW file as it flows through a Recipe's actions.
+// Actions read and mutate it in place; later actions see the effects of
+// earlier ones (e.g. rotate runs after extract has set JpegPath).
+type File struct {
+	SrcPath     string
+	JpegPath    string
+	Orientation int
+	Quality     int
+}
+
+// Pipeline is passed to every Action.Run call: Config is shared and
+// read-only for the action, File is specific to the file currently being
+// processed.
+type Pipeline struct {
+	Config Config
+	File   *File
+}
+
+// Action is a single pipeline stage, e.g. extract, rotate, resize, rename,
+// upload or index.
+type Action interface {
+	// Run executes this stage for p.File, mutating it as needed for later
+	// stages. The built-in actions do not consult ctx: once Run has
+	// started a file, it goes through every stage even if ctx is cancelled.
+	Run(ctx context.Context, p *Pipeline) error
+}
+
+// Factory builds an Action from the parameters given for it in a recipe's
+// action list (the YAML map for that list entry, minus the "type" key).
+type Factory func(spec map[string]interface{}) (Action, error)
+
+var factories = make(map[string]Factory)
+
+// Register associates a recipe action "type" (e.g. "extract") with a
+// Factory. Register panics if kind is already registered.
+func Register(kind string, factory Factory) {
+	if _, exists := factories[kind]; exists {
+		panic("actions: Register called twice for kind " + kind)
+	}
+	factories[kind] = factory
+}
+
+// New builds the Action named by kind using spec. It returns an error if
+// kind has no registered Factory or the Factory rejects spec.
+func New(kind string, spec map[string]interface{}) (Action, error) {
+	factory, ok := factories[kind]
+	if !ok {
+		return nil, fmt.Errorf("actions: unknown action type %q", kind)
+	}
+	return factory(spec)
+}
+
+// stringParam returns spec[key] as a string, or def if the key is absent.
+func stringParam(spec map[string]interface{}, key, def string) string {
+	if v, ok := spec[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return def
+}
+
+// intParam returns spec[key] as an int, or def if the key is absent or its
+// value is not an int. yaml.v2 decodes YAML integers as int, so no other
+// numeric type is handled; a float such as 80.5 yields def.
+func intParam(spec map[string]interface{}, key string, def int) int {
+	switch v := spec[key].(type) {
+	case int:
+		return v
+	default:
+		return def
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+
+	"github.com/jeremytorres/jpegextract/parsers"
+	"github.com/jeremytorres/jpegextract/rotate"
+	"github.com/jeremytorres/rawparser"
+)
+
+func init() {
+	Register("extract", newExtractAction)
+}
+
+// extractAction extracts the embedded JPEG from a RawFile using the parser
+// named by its "parser" spec key (e.g. "NEF", "CR2"; see parsers.Keys).
+type extractAction struct {
+	rawType string
+	quality int
+	parser  rawparser.RawParser
+}
+
+func newExtractAction(spec map[string]interface{}) (Action, error) {
+	rawType := stringParam(spec, "parser", "")
+	if rawType == "" {
+		return nil, fmt.Errorf("extract: recipe action missing required \"parser\" field")
+	}
+	if !parsers.IsRegistered(rawType) {
+		return nil, fmt.Errorf("extract: no parser registered for %q", rawType)
+	}
+	return &extractAction{
+		rawType: rawType,
+		quality: intParam(spec, "quality", 0),
+		parser:  parsers.New(rawType, isHostLittleEndian()),
+	}, nil
+}
+
+func (a *extractAction) Run(ctx context.Context, p *Pipeline) error {
+	quality := a.quality
+	if quality == 0 {
+		quality = p.Config.Quality
+	}
+
+	rawfile, err := a.parser.ProcessFile(&rawparser.RawFileInfo{p.File.SrcPath, p.Config.DestDir, quality})
+	if err != nil {
+		return fmt.Errorf("extract: %v", err)
+	}
+
+	p.File.JpegPath = rawfile.JpegPath
+	p.File.Quality = quality
+	p.File.Orientation = rotate.OrientationFromRadians(rawfile.JpegOrientation)
+	return nil
+}
+
+// isHostLittleEndian reports whether the running process is little-endian.
+// Duplicated from apputils.go (package main can't be imported by this
+// package) rather than introducing a shared dependency for five lines.
+func isHostLittleEndian() bool {
+	var i int32 = 0x01020304
+	u := unsafe.Pointer(&i)
+	pb := (*byte)(u)
+	b := *pb
+	return b == 0x04
+}
@@ -0,0 +1,174 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Recipe is the top-level shape of a --recipe YAML document.
+type Recipe struct {
+	// Src is a list of patterns describing which files enter the pipeline.
+	// A pattern with no "**" segment is a single-level filepath.Glob (e.g.
+	// "/photos/*.NEF" only matches files directly under /photos). A pattern
+	// containing "**" (e.g. "/photos/**/*.NEF") instead walks every
+	// subdirectory under the part of the pattern before the "**", matching
+	// the part after it against each file's base name.
+	Src []string `yaml:"src"`
+	// NumRoutines bounds how many files are processed concurrently.
+	NumRoutines int `yaml:"num-routines"`
+	// DestDir is the default Config.DestDir actions run against.
+	DestDir string `yaml:"dest-dir"`
+	// Quality is the default Config.Quality actions run against.
+	Quality int `yaml:"quality"`
+	// Actions is the ordered list of pipeline stages. Each entry must
+	// include a "type" key naming a registered Action.
+	Actions []map[string]interface{} `yaml:"actions"`
+}
+
+// LoadRecipe reads and parses the YAML recipe at path.
+func LoadRecipe(path string) (*Recipe, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("actions: unable to read recipe '%s': %v", path, err)
+	}
+
+	var r Recipe
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("actions: unable to parse recipe '%s': %v", path, err)
+	}
+	if r.NumRoutines <= 0 {
+		r.NumRoutines = 2
+	}
+	return &r, nil
+}
+
+// expandSrcPattern resolves one Recipe.Src pattern to the files it matches.
+// filepath.Glob (and therefore a plain pattern here) only ever matches a
+// single path segment per "*", never crossing a directory separator; a
+// pattern containing "**" instead walks every subdirectory under the part
+// of the pattern before the "**", matching the part after it (with ordinary
+// filepath.Match wildcards) against each file's base name.
+func expandSrcPattern(pattern string) ([]string, error) {
+	idx := strings.Index(pattern, "**")
+	if idx < 0 {
+		return filepath.Glob(pattern)
+	}
+
+	root := filepath.Dir(pattern[:idx])
+	suffix := strings.TrimPrefix(pattern[idx+2:], "/")
+
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		matched, err := filepath.Match(suffix, filepath.Base(path))
+		if err != nil {
+			return err
+		}
+		if matched {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Run builds every action in recipe and streams each file matched by
+// recipe.Src through them, using a worker pool bounded by
+// recipe.NumRoutines. It returns the number of files that completed every
+// stage without error. Run stops starting new files once ctx is done, but
+// lets in-flight files run through every remaining stage so cache/index
+// writes from other actions aren't left half-done.
+func Run(ctx context.Context, recipe *Recipe) (int, error) {
+	stages := make([]Action, 0, len(recipe.Actions))
+	for _, spec := range recipe.Actions {
+		kind, _ := spec["type"].(string)
+		if kind == "" {
+			return 0, fmt.Errorf("actions: recipe action missing required \"type\" field")
+		}
+		action, err := New(kind, spec)
+		if err != nil {
+			return 0, err
+		}
+		stages = append(stages, action)
+	}
+
+	var files []string
+	for _, pattern := range recipe.Src {
+		matches, err := expandSrcPattern(pattern)
+		if err != nil {
+			return 0, fmt.Errorf("actions: invalid src pattern %q: %v", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+
+	cfg := Config{DestDir: recipe.DestDir, Quality: recipe.Quality}
+	sem := make(chan struct{}, recipe.NumRoutines)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	completed := 0
+
+	for _, src := range files {
+		if ctx.Err() != nil {
+			log.Printf("actions: run cancelled before starting all matched files\n")
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(src string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			p := &Pipeline{Config: cfg, File: &File{SrcPath: src}}
+			for _, stage := range stages {
+				if err := stage.Run(ctx, p); err != nil {
+					log.Printf("actions: error processing '%s': %v\n", src, err)
+					return
+				}
+			}
+
+			mu.Lock()
+			completed++
+			mu.Unlock()
+		}(src)
+	}
+
+	wg.Wait()
+	return completed, nil
+}
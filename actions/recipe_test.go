@@ -0,0 +1,92 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestExpandSrcPatternRecursive(t *testing.T) {
+	root := t.TempDir()
+
+	paths := []string{
+		filepath.Join(root, "a.NEF"),
+		filepath.Join(root, "2024", "b.NEF"),
+		filepath.Join(root, "2024", "06", "c.NEF"),
+		filepath.Join(root, "2024", "06", "d.CR2"),
+	}
+	for _, p := range paths {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll(%s): %v", filepath.Dir(p), err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatalf("WriteFile(%s): %v", p, err)
+		}
+	}
+
+	got, err := expandSrcPattern(filepath.Join(root, "**", "*.NEF"))
+	if err != nil {
+		t.Fatalf("expandSrcPattern: %v", err)
+	}
+	sort.Strings(got)
+
+	want := []string{paths[0], paths[1], paths[2]}
+	sort.Strings(want)
+
+	if len(got) != len(want) {
+		t.Fatalf("expandSrcPattern returned %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expandSrcPattern[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExpandSrcPatternSingleLevel(t *testing.T) {
+	root := t.TempDir()
+
+	top := filepath.Join(root, "a.NEF")
+	nested := filepath.Join(root, "sub", "b.NEF")
+	if err := os.WriteFile(top, nil, 0644); err != nil {
+		t.Fatalf("WriteFile(%s): %v", top, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(nested), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(nested, nil, 0644); err != nil {
+		t.Fatalf("WriteFile(%s): %v", nested, err)
+	}
+
+	got, err := expandSrcPattern(filepath.Join(root, "*.NEF"))
+	if err != nil {
+		t.Fatalf("expandSrcPattern: %v", err)
+	}
+	if len(got) != 1 || got[0] != top {
+		t.Errorf("expandSrcPattern (no **) = %v, want [%s]", got, top)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+func init() {
+	Register("rename", newRenameAction)
+}
+
+// renameFields are the placeholders a rename action's "template" spec key
+// may reference. rawparser does not currently expose a RawFile's EXIF
+// capture date or camera model, so {{.Date}} falls back to the extracted
+// JPEG's mtime and {{.Camera}} is always empty; both will start reflecting
+// real EXIF data once rawparser surfaces it.
+type renameFields struct {
+	Basename string
+	Ext      string
+	Date     string
+	Camera   string
+}
+
+// renameAction moves p.File.JpegPath to a new path computed from its
+// "template" spec key, e.g. "{{.Date}}/{{.Camera}}/{{.Basename}}{{.Ext}}".
+// Parent directories are created as needed.
+type renameAction struct {
+	tmpl *template.Template
+}
+
+func newRenameAction(spec map[string]interface{}) (Action, error) {
+	tmplStr := stringParam(spec, "template", "")
+	if tmplStr == "" {
+		return nil, fmt.Errorf("rename: recipe action missing required \"template\" field")
+	}
+	tmpl, err := template.New("rename").Parse(tmplStr)
+	if err != nil {
+		return nil, fmt.Errorf("rename: invalid template: %v", err)
+	}
+	return &renameAction{tmpl: tmpl}, nil
+}
+
+func (a *renameAction) Run(ctx context.Context, p *Pipeline) error {
+	path := p.File.JpegPath
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(filepath.Base(path), ext)
+
+	date := ""
+	if info, err := os.Stat(path); err == nil {
+		date = info.ModTime().Format("2006-01-02")
+	} else {
+		date = time.Now().Format("2006-01-02")
+	}
+
+	var buf bytes.Buffer
+	fields := renameFields{Basename: base, Ext: ext, Date: date, Camera: ""}
+	if err := a.tmpl.Execute(&buf, fields); err != nil {
+		return fmt.Errorf("rename: %v", err)
+	}
+
+	dest := filepath.Join(p.Config.DestDir, buf.String())
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("rename: unable to create '%s': %v", filepath.Dir(dest), err)
+	}
+	if err := os.Rename(path, dest); err != nil {
+		return fmt.Errorf("rename: unable to move '%s' to '%s': %v", path, dest, err)
+	}
+	p.File.JpegPath = dest
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+)
+
+func init() {
+	Register("resize", newResizeAction)
+}
+
+// resizeAction downscales p.File.JpegPath so neither dimension exceeds its
+// "max-dim" spec key, preserving aspect ratio. Files already within bounds
+// are left untouched. Resizing uses only the standard library: it uses a
+// simple nearest-neighbor sampler rather than a higher-quality (e.g. Lanczos)
+// filter, which is a fine tradeoff for a thumbnail-sized constraint like
+// "max-dim".
+type resizeAction struct {
+	maxDim int
+}
+
+func newResizeAction(spec map[string]interface{}) (Action, error) {
+	maxDim := intParam(spec, "max-dim", 0)
+	if maxDim <= 0 {
+		return nil, fmt.Errorf("resize: recipe action missing required \"max-dim\" field")
+	}
+	return &resizeAction{maxDim: maxDim}, nil
+}
+
+func (a *resizeAction) Run(ctx context.Context, p *Pipeline) error {
+	path := p.File.JpegPath
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("resize: unable to open '%s': %v", path, err)
+	}
+	src, err := jpeg.Decode(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("resize: unable to decode '%s': %v", path, err)
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= a.maxDim && h <= a.maxDim {
+		return nil
+	}
+
+	scale := float64(a.maxDim) / float64(w)
+	if hScale := float64(a.maxDim) / float64(h); hScale < scale {
+		scale = hScale
+	}
+	dstW := int(float64(w) * scale)
+	dstH := int(float64(h) * scale)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		srcY := b.Min.Y + int(float64(y)/scale)
+		for x := 0; x < dstW; x++ {
+			srcX := b.Min.X + int(float64(x)/scale)
+			dst.Set(x, y, src.At(srcX, srcY))
+		}
+	}
+
+	quality := p.File.Quality
+	if quality == 0 {
+		quality = p.Config.Quality
+	}
+
+	tmp := path + ".resizing.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("resize: unable to create '%s': %v", tmp, err)
+	}
+	if err := jpeg.Encode(out, dst, &jpeg.Options{Quality: quality}); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("resize: unable to encode '%s': %v", path, err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,78 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package actions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeremytorres/jpegextract/rotate"
+)
+
+func init() {
+	Register("rotate", newRotateAction)
+}
+
+// rotateAction rotates p.File.JpegPath according to the orientation an
+// earlier extract stage recorded on it. Its "mode" spec key is "exif"
+// (default; rotate using the extracted orientation) or "none" (disable,
+// useful for temporarily turning a stage off without removing it from the
+// recipe). Its "engine" spec key is "gopure" (default) or "imagemagick".
+type rotateAction struct {
+	enabled bool
+	engine  rotate.Engine
+}
+
+func newRotateAction(spec map[string]interface{}) (Action, error) {
+	mode := stringParam(spec, "mode", "exif")
+	engineName := stringParam(spec, "engine", "gopure")
+
+	var engine rotate.Engine
+	switch engineName {
+	case "gopure":
+		engine = rotate.GoPure
+	case "imagemagick":
+		engine = rotate.ImageMagick
+	default:
+		return nil, fmt.Errorf("rotate: unknown engine %q (want \"gopure\" or \"imagemagick\")", engineName)
+	}
+
+	switch mode {
+	case "exif", "none":
+	default:
+		return nil, fmt.Errorf("rotate: unknown mode %q (want \"exif\" or \"none\")", mode)
+	}
+
+	return &rotateAction{enabled: mode == "exif", engine: engine}, nil
+}
+
+func (a *rotateAction) Run(ctx context.Context, p *Pipeline) error {
+	if !a.enabled || p.File.Orientation == rotate.Normal {
+		return nil
+	}
+	if err := rotate.JpegFile(p.File.JpegPath, p.File.Orientation, p.File.Quality, a.engine); err != nil {
+		return fmt.Errorf("rotate: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	Register("upload", newUploadAction)
+}
+
+// uploadAction copies p.File.JpegPath to another location once the rest of
+// the pipeline is done with it. Its "target" spec key selects the
+// destination kind and its "dest" spec key gives the destination path
+// (a directory for "local").
+//
+// Only "local" is implemented. S3/B2 clients are reachable through the
+// module proxy, but picking one (and its auth/retry/region knobs) is a
+// decision a recipe author should make explicitly, not one this command
+// should bake in; "s3" and "b2" targets fail fast with an error explaining
+// what's missing rather than silently doing nothing.
+type uploadAction struct {
+	target string
+	dest   string
+}
+
+func newUploadAction(spec map[string]interface{}) (Action, error) {
+	target := stringParam(spec, "target", "local")
+	dest := stringParam(spec, "dest", "")
+	if dest == "" {
+		return nil, fmt.Errorf("upload: recipe action missing required \"dest\" field")
+	}
+	switch target {
+	case "local", "s3", "b2":
+	default:
+		return nil, fmt.Errorf("upload: unknown target %q (want \"local\", \"s3\" or \"b2\")", target)
+	}
+	return &uploadAction{target: target, dest: dest}, nil
+}
+
+func (a *uploadAction) Run(ctx context.Context, p *Pipeline) error {
+	switch a.target {
+	case "s3", "b2":
+		return fmt.Errorf("upload: target %q requires a cloud SDK not vendored in this build; add one and extend newUploadAction", a.target)
+	}
+
+	src, err := os.Open(p.File.JpegPath)
+	if err != nil {
+		return fmt.Errorf("upload: unable to open '%s': %v", p.File.JpegPath, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(a.dest, 0755); err != nil {
+		return fmt.Errorf("upload: unable to create '%s': %v", a.dest, err)
+	}
+
+	destPath := filepath.Join(a.dest, filepath.Base(p.File.JpegPath))
+	dst, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("upload: unable to create '%s': %v", destPath, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("upload: unable to copy to '%s': %v", destPath, err)
+	}
+	return dst.Close()
+}
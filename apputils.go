@@ -25,9 +25,11 @@ package main
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"unsafe"
 )
 
@@ -72,6 +74,36 @@ func validateUserDir(dirStr string) (isValid bool, errStr string) {
 	return isValid, errStr
 }
 
-func getFilesForExt(ext string) ([]string, error) {
-	return filepath.Glob(ext)
+// walkRawFiles walks dirs (recursively) and returns every file whose
+// extension (case-insensitively) matches one of exts. The whole list is
+// collected before it returns; no file is handed out while the walk is
+// still in progress. It replaces the old
+// eager filepath.Glob-per-extension approach, which only matched a single
+// directory level and required a separate walk of each directory for every
+// extension.
+func walkRawFiles(dirs []string, exts []string) ([]string, error) {
+	wanted := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		wanted["."+strings.ToLower(ext)] = true
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if wanted[strings.ToLower(filepath.Ext(path))] {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return files, err
+		}
+	}
+	return files, nil
 }
@@ -0,0 +1,179 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDigestStableAndSensitiveToMtime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "raw.NEF")
+	if err := os.WriteFile(path, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	d1, err := Digest(path, false)
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	d2, err := Digest(path, false)
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	if d1 != d2 {
+		t.Errorf("Digest not stable: %s != %s", d1, d2)
+	}
+
+	// mtime is part of the digest, so touching the file must change it.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	d3, err := Digest(path, false)
+	if err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	if d3 == d1 {
+		t.Error("Digest did not change after mtime update")
+	}
+}
+
+func TestEntryMatches(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.jpg")
+	if err := os.WriteFile(out, nil, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	e := Entry{OutputPath: out, Quality: 80, Rotate: true}
+	if !e.Matches(80, true) {
+		t.Error("Matches: expected hit for identical params with output present")
+	}
+	if e.Matches(70, true) {
+		t.Error("Matches: expected miss on quality mismatch")
+	}
+	if e.Matches(80, false) {
+		t.Error("Matches: expected miss on rotate mismatch")
+	}
+
+	if err := os.Remove(out); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if e.Matches(80, true) {
+		t.Error("Matches: expected miss once output file is gone")
+	}
+}
+
+func TestStorePutLookupPersists(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	entry := Entry{OutputPath: "/x/out.jpg", Quality: 80, Rotate: true, ExtractedAt: time.Now()}
+	if err := s.Put("digest-1", entry); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open (reopen): %v", err)
+	}
+	got, ok := reopened.Lookup("digest-1")
+	if !ok {
+		t.Fatal("Lookup: expected entry to survive Close/reopen")
+	}
+	if got.OutputPath != entry.OutputPath || got.Quality != entry.Quality {
+		t.Errorf("Lookup = %+v, want %+v", got, entry)
+	}
+}
+
+func TestStorePutThrottlesDiskWrites(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, indexFileName)
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// The very first Put has no prior save to throttle against, so it
+	// writes through immediately.
+	if err := s.Put("digest-1", Entry{Quality: 80}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	first, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("ReadFile after first Put: %v", err)
+	}
+
+	// A second Put landing inside saveInterval of the first must not
+	// trigger another full rewrite.
+	if err := s.Put("digest-2", Entry{Quality: 80}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	second, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("ReadFile after second Put: %v", err)
+	}
+	if string(second) != string(first) {
+		t.Error("Put rewrote the index file before saveInterval elapsed")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open (reopen): %v", err)
+	}
+	if _, ok := reopened.Lookup("digest-2"); !ok {
+		t.Error("Lookup: expected digest-2 to be flushed by Close")
+	}
+}
+
+func TestStoreReset(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := s.Put("digest-1", Entry{Quality: 80}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s.Reset(); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if _, ok := s.Lookup("digest-1"); ok {
+		t.Error("Lookup: expected entry to be gone after Reset")
+	}
+}
@@ -28,18 +28,29 @@
 // Usage:
 //     jpgextract --raws "NEF,CR2" --dest-dir "/path_to/output_dir"
 //                --src-dirs "/path_to/source1,/path_to/source2"
-//                [--num-routines "8" --quality "80" --rotate]
+//                [--num-routines "8" --quality "80" --rotate --use-imagemagick]
+//
+// Alternatively, a multi-stage pipeline (extract, rotate, resize, rename,
+// upload, index) can be declared in a YAML recipe and run with:
+//     jpgextract --recipe "pipeline.yaml"
+// See the actions package for the recipe format.
 package main
 
 import (
+	"context"
 	"github.com/codegangsta/cli"
+	"github.com/jeremytorres/jpegextract/actions"
+	"github.com/jeremytorres/jpegextract/cache"
+	"github.com/jeremytorres/jpegextract/parsers"
+	_ "github.com/jeremytorres/jpegextract/parsers/cr2"
+	_ "github.com/jeremytorres/jpegextract/parsers/nef"
+	"github.com/jeremytorres/jpegextract/rotate"
 	"github.com/jeremytorres/rawparser"
 	"log"
-	"math"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -66,26 +77,48 @@ const (
 	// when processing the embedded JPEG in a RawFile.
 	QualityKey = "quality"
 	// RotateKey is the constant representing the command line argument indicating rotation of
-	// JPEGs should occur based on the EXIF info embedded within the RawFile.  ImageMagic's 'convert'
-	// utility is used and is checked at startup for existence.
+	// JPEGs should occur based on the EXIF info embedded within the RawFile.  By default this is
+	// performed in-process with Go's standard image libraries; pass UseImageMagickKey to shell out
+	// to ImageMagick's 'convert' instead.
 	RotateKey = "rotate"
+	// UseImageMagickKey is the constant representing the command line argument indicating that
+	// rotation should be performed via ImageMagick's 'convert' utility rather than the default
+	// pure-Go rotation pipeline.  'convert' must be in PATH when this is set.
+	UseImageMagickKey = "use-imagemagick"
+	// CacheDirKey is the constant representing the command line argument for the directory holding
+	// the content-addressable extraction cache.  When unset, no cache is used.
+	CacheDirKey = "cache-dir"
+	// NoCacheKey is the constant representing the command line argument that disables the
+	// extraction cache even when CacheDirKey is set.
+	NoCacheKey = "no-cache"
+	// RebuildCacheKey is the constant representing the command line argument that discards any
+	// existing cache entries under CacheDirKey before processing, forcing every RawFile to be
+	// re-extracted.
+	RebuildCacheKey = "rebuild-cache"
+	// FullHashKey is the constant representing the command line argument indicating the extraction
+	// cache should digest the full contents of each RawFile rather than the faster sample-based
+	// digest described in cache.Digest.
+	FullHashKey = "full-hash"
+	// RecipeKey is the constant representing the command line argument for a YAML recipe file (see
+	// the actions package) describing a multi-stage pipeline.  When set, it replaces the one-shot
+	// extract+rotate flow driven by RawTypesKey/SrcDirKey/DestDirKey.  CacheDirKey (and NoCacheKey/
+	// RebuildCacheKey/FullHashKey) only apply to that one-shot flow; a recipe always re-processes
+	// every file its Src patterns match, regardless of CacheDirKey.
+	RecipeKey = "recipe"
 	// AppVersionKey is the constant defining the current version of this command utility.
 	AppVersionKey = "1.0"
 	// ImageMagicConvertBin is the constant representing ImageMagic's 'convert' utility.
-	ImageMagicConvertBin = "convert"
+	ImageMagicConvertBin = rotate.ImageMagicConvertBin
 )
 
 var (
-	destDir, sqlLiteDb     string
-	rawFileExts, srcDirs   []string
-	numOfRoutines, quality int
-	rotate                 bool
-	parsers                *rawparser.RawParsers
-	// validParserKeys is a slice of RAW file parsers supported by this implementation.
-	validParserKeys = []string{
-		rawparser.NefParserKey,
-		rawparser.Cr2ParserKey,
-	}
+	destDir, cacheDir, recipePath   string
+	rawFileExts, srcDirs            []string
+	numOfRoutines, quality          int
+	rotateEnabled, useImageMagick   bool
+	noCache, rebuildCache, fullHash bool
+	rawParsers                      *rawparser.RawParsers
+	extractionCache                 *cache.Store
 )
 
 /*
@@ -121,13 +154,12 @@ func processFilesConcurrent(rp *RawFileParserPair, c chan<- bool) {
 }
 */
 
+// isRawFileExtValid reports whether ext has a RAW parser registered for it.
+// Supported extensions are no longer hard-coded here; they come from
+// whichever parsers/* packages are blank-imported above, so adding a new
+// format only requires a new subpackage, not an edit to this file.
 func isRawFileExtValid(ext string) bool {
-	for _, validExt := range validParserKeys {
-		if ext == validExt {
-			return true
-		}
-	}
-	return false
+	return parsers.IsRegistered(ext)
 }
 
 // processCli parses command line arguments and checks for validity of user-specified
@@ -148,15 +180,32 @@ func processCli() bool {
 		cli.StringFlag{DestDirKey, "", "the full path to the directory containing extracted jpegs"},
 		cli.IntFlag{NumRoutinesKey, 2, "the number of concurrent files to be processed"},
 		cli.IntFlag{QualityKey, 80, "JPEG encoding quality used for extracted jpegs"},
-		cli.BoolFlag{RotateKey, "ImageMagic's 'convert' command will be used to rotate jpegs based on EXIF info from RawFile.  'convert' must be in PATH"},
+		cli.BoolFlag{RotateKey, "extracted jpegs will be rotated based on EXIF orientation info from the RawFile"},
+		cli.BoolFlag{UseImageMagickKey, "perform rotation via ImageMagic's 'convert' command instead of the default pure-Go rotation.  'convert' must be in PATH"},
+		cli.StringFlag{CacheDirKey, "", "directory used to persist the content-addressable extraction cache; RawFiles already present in the cache are skipped.  Not used by --recipe, which always re-processes every matched file"},
+		cli.BoolFlag{NoCacheKey, "disable the extraction cache even when cache-dir is set"},
+		cli.BoolFlag{RebuildCacheKey, "discard any existing cache entries under cache-dir before processing"},
+		cli.BoolFlag{FullHashKey, "digest the full contents of each RawFile for the extraction cache, instead of a fast sample-based digest"},
+		cli.StringFlag{RecipeKey, "", "path to a YAML recipe describing a multi-stage extraction pipeline; overrides --raws/--src-dirs/--dest-dir"},
 	}
 	app.Action = func(c *cli.Context) {
+		recipePath = strings.TrimSpace(c.String(RecipeKey))
+		if recipePath != "" {
+			processed = true
+			return
+		}
+
 		rawExts := strings.TrimSpace(c.String(RawTypesKey))
 		srcDir := strings.TrimSpace(c.String(SrcDirKey))
 		destDir = strings.TrimSpace(c.String(DestDirKey))
 		numOfRoutines = c.Int(NumRoutinesKey)
 		quality = c.Int(QualityKey)
-		rotate = c.Bool(RotateKey)
+		rotateEnabled = c.Bool(RotateKey)
+		useImageMagick = c.Bool(UseImageMagickKey)
+		cacheDir = strings.TrimSpace(c.String(CacheDirKey))
+		noCache = c.Bool(NoCacheKey)
+		rebuildCache = c.Bool(RebuildCacheKey)
+		fullHash = c.Bool(FullHashKey)
 
 		// src and dest dirs required; remaing args have sane defaults
 		if rawExts == "" || srcDir == "" || destDir == "" {
@@ -164,8 +213,8 @@ func processCli() bool {
 			os.Exit(1)
 		}
 
-		if rotate && !isImagicConvertInPath(ImageMagicConvertBin) {
-			log.Fatal("Rotation of jpegs was enables, but ImageMagic's 'convert' is not in path!")
+		if rotateEnabled && useImageMagick && !isImagicConvertInPath(ImageMagicConvertBin) {
+			log.Fatal("Rotation via ImageMagic was enabled, but 'convert' is not in path!")
 			exitWithErr()
 		}
 
@@ -214,93 +263,205 @@ func processCli() bool {
 	return processed
 }
 
+// initParsers instantiates a rawparser.RawParser for every format registered
+// via the parsers package (see the blank imports above) rather than
+// hard-coding the set of formats this command knows about.
 func initParsers() {
-	parsers = rawparser.NewRawParsers()
-	cr2Parser, cr2Key := rawparser.NewCr2Parser(isHostLittleEndian())
-	parsers.Register(cr2Key, cr2Parser)
+	rawParsers = rawparser.NewRawParsers()
+	littleEndian := isHostLittleEndian()
+	for _, key := range parsers.Keys() {
+		rawParsers.Register(key, parsers.New(key, littleEndian))
+	}
+}
+
+// rotateEngine reports which rotate.Engine the --use-imagemagick flag
+// selects.
+func rotateEngine() rotate.Engine {
+	if useImageMagick {
+		return rotate.ImageMagick
+	}
+	return rotate.GoPure
+}
+
+// rotateNumRoutines bounds how many rotations doProcess runs concurrently:
+// half of numOfRoutines, with a floor of 1, so a burst of rotation work
+// can't starve extraction of its own worker slots.
+func rotateNumRoutines() int {
+	n := numOfRoutines / 2
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
 
-	nefParser, nefKey := rawparser.NewNefParser(isHostLittleEndian())
-	parsers.Register(nefKey, nefParser)
+// lookupCacheDigest returns the cache digest for file, or "" if the
+// extraction cache is disabled or the digest could not be computed.
+func lookupCacheDigest(file string) string {
+	if extractionCache == nil {
+		return ""
+	}
+	digest, err := cache.Digest(file, fullHash)
+	if err != nil {
+		log.Printf("Error computing cache digest for '%s': %v\n", file, err)
+		return ""
+	}
+	return digest
+}
+
+// initCache opens the extraction cache under cacheDir, honoring --no-cache
+// and --rebuild-cache.  It is a no-op when cacheDir is unset.
+func initCache() {
+	if cacheDir == "" || noCache {
+		return
+	}
+	store, err := cache.Open(cacheDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if rebuildCache {
+		if err := store.Reset(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	extractionCache = store
 }
 
-func doProcess() int {
+// doProcess walks srcDirs for files matching rawFileExts and extracts (and,
+// if enabled, rotates) each one, bounded by two worker pools: one of size
+// numOfRoutines for extraction, and a smaller one (rotateNumRoutines) for
+// rotation, so a burst of rotations can't block extraction from making
+// progress. ctx is checked before dispatching each file so a cancelled run
+// (e.g. on SIGINT) stops starting new work while letting in-flight files
+// finish, flushing their cache entries normally.
+func doProcess(ctx context.Context) int {
 	log.Printf("RawTypes: %v SourceDirs: %v DestinationDir: %s JPEG Quality: %d Rotate Images: %v\n",
-		rawFileExts, srcDirs, destDir, quality, rotate)
+		rawFileExts, srcDirs, destDir, quality, rotateEnabled)
+
+	files, err := walkRawFiles(srcDirs, rawFileExts)
+	if err != nil {
+		log.Printf("Error walking source directories: %v\n", err)
+	}
+	log.Printf("Found %d raw file(s) to process with %d extraction routine(s) (%d NumCPU)\n",
+		len(files), numOfRoutines, runtime.NumCPU())
+
+	extractSem := make(chan struct{}, numOfRoutines)
+	rotateSem := make(chan struct{}, rotateNumRoutines())
 
 	var done sync.WaitGroup
-	total := 0
-	finish := make(chan struct{})
-
-	// process all src dirs
-	for _, dir := range srcDirs {
-
-		// process all raw file types
-		for i, rawType := range rawFileExts {
-
-			globPattern := dir + "*." + rawType
-			files, _ := getFilesForExt(globPattern)
-			fileCnt := len(files)
-
-			parser := parsers.GetParser(rawType)
-
-			if fileCnt > 0 {
-				log.Printf("Raw Type: %s ==> Processing '%s' %d files with %d NumCPU:\n",
-					rawFileExts[i], dir, len(files), runtime.NumCPU())
-
-				for _, file := range files {
-					done.Add(1)
-
-					go func(c chan struct{}, file string) {
-						rawfile, err := parser.ProcessFile(&rawparser.RawFileInfo{file, destDir, quality})
-						if err != nil {
-							log.Printf("Error with file: '%s'.  Error: %v\n", file, err)
-						} else {
-							if rotate && rawfile.JpegOrientation != 0.0 {
-								// rotate jpeg
-								go func(fileName string, radiansCw float64) {
-									degrees := radiansCw * (180 / math.Pi)
-									log.Printf("Rotating image %f degrees for jpeg: '%s'\n", degrees, fileName)
-									cmd := exec.Command(ImageMagicConvertBin, "-rotate", strconv.FormatFloat(degrees, 'f', 2, 64), fileName, fileName)
-									err := cmd.Start()
-									if err != nil {
-										log.Fatal(err)
-									}
-									err = cmd.Wait()
-									if err != nil {
-										log.Printf("Command finished with error: %v", err)
-									}
-								}(rawfile.JpegPath, rawfile.JpegOrientation)
-							}
-						}
-
-						select {
-						case <-c:
-						}
-						// signal completion of work
-						done.Done()
-
-					}(finish, file)
+	var mu sync.Mutex
+	started := 0
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			log.Printf("doProcess: cancelled; not starting remaining %d file(s)\n", len(files)-started)
+			break
+		}
+
+		ext := strings.ToUpper(strings.TrimPrefix(filepath.Ext(file), "."))
+		parser := rawParsers.GetParser(ext)
+		if parser == nil {
+			continue
+		}
+
+		mu.Lock()
+		started++
+		mu.Unlock()
+
+		done.Add(1)
+		extractSem <- struct{}{}
+
+		go func(file string, parser rawparser.RawParser) {
+			defer done.Done()
+
+			digest := lookupCacheDigest(file)
+			if digest != "" {
+				if entry, ok := extractionCache.Lookup(digest); ok && entry.Matches(quality, rotateEnabled) {
+					<-extractSem
+					log.Printf("Cache hit for '%s'; skipping\n", file)
+					return
 				}
-				total += fileCnt
 			}
-		}
-	}
 
-	close(finish)
+			rawfile, err := parser.ProcessFile(&rawparser.RawFileInfo{file, destDir, quality})
+			<-extractSem
+			if err != nil {
+				log.Printf("Error with file: '%s'.  Error: %v\n", file, err)
+				return
+			}
+			rotateFailed := false
+			if rotateEnabled && rawfile.JpegOrientation != 0.0 {
+				orientation := rotate.OrientationFromRadians(rawfile.JpegOrientation)
+				log.Printf("Rotating jpeg '%s' (orientation %d)\n", rawfile.JpegPath, orientation)
+
+				rotateSem <- struct{}{}
+				if err := rotate.JpegFile(rawfile.JpegPath, orientation, quality, rotateEngine()); err != nil {
+					log.Printf("Error rotating file: '%s'.  Error: %v\n", rawfile.JpegPath, err)
+					rotateFailed = true
+				}
+				<-rotateSem
+			}
+
+			if digest != "" && !rotateFailed {
+				entry := cache.Entry{OutputPath: rawfile.JpegPath, Quality: quality, Rotate: rotateEnabled, ExtractedAt: time.Now()}
+				if err := extractionCache.Put(digest, entry); err != nil {
+					log.Printf("Error updating extraction cache for '%s': %v\n", file, err)
+				}
+			}
+		}(file, parser)
+	}
 
 	done.Wait()
 
-	return total
+	if extractionCache != nil {
+		if err := extractionCache.Close(); err != nil {
+			log.Printf("Error flushing extraction cache: %v\n", err)
+		}
+	}
+
+	return started
 }
 
 func setup() {
 	success := processCli()
-	if success {
-		runtime.GOMAXPROCS(numOfRoutines)
-		initParsers()
-	} else {
+	if !success {
 		exitWithErr()
+		return
+	}
+	if recipePath != "" {
+		return
+	}
+	initParsers()
+	initCache()
+}
+
+// runRecipe loads and executes the pipeline described by the --recipe flag,
+// in place of the one-shot extract+rotate flow in doProcess.
+func runRecipe(ctx context.Context) int {
+	recipe, err := actions.LoadRecipe(recipePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cnt, err := actions.Run(ctx, recipe)
+	if err != nil {
+		log.Fatal(err)
 	}
+	return cnt
+}
+
+// interruptContext returns a context that is cancelled on the first SIGINT,
+// so doProcess/runRecipe stop starting new work but let files already
+// in-flight finish, flushing their cache/index writes normally instead of
+// leaving them half-done.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		<-sigCh
+		log.Println("jpgextract: interrupt received, finishing in-flight files before exiting...")
+		cancel()
+	}()
+	return ctx
 }
 
 func main() {
@@ -308,7 +469,14 @@ func main() {
 
 	setup()
 
-	cnt := doProcess()
+	ctx := interruptContext()
+
+	var cnt int
+	if recipePath != "" {
+		cnt = runRecipe(ctx)
+	} else {
+		cnt = doProcess(ctx)
+	}
 
 	duration := time.Since(t0)
 
@@ -0,0 +1,40 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+// Package nef registers rawparser's NEF (Nikon RAW) parser with the parsers
+// registry. Blank-import this package to enable NEF support:
+//
+//     import _ "github.com/jeremytorres/jpegextract/parsers/nef"
+package nef
+
+import (
+	"github.com/jeremytorres/jpegextract/parsers"
+	"github.com/jeremytorres/rawparser"
+)
+
+func init() {
+	parsers.Register(rawparser.NefParserKey, func(littleEndian bool) rawparser.RawParser {
+		parser, _ := rawparser.NewNefParser(littleEndian)
+		return parser
+	})
+}
@@ -0,0 +1,77 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+// Package parsers is an extension registry for rawparser.RawParser
+// implementations. Each supported RAW format lives in its own subpackage
+// (e.g. parsers/nef, parsers/cr2) whose init() calls Register; callers
+// discover the set of supported formats by blank-importing the subpackages
+// they want and consulting Keys(), rather than main.go hard-coding every
+// format it knows about.
+//
+// Formats for which github.com/jeremytorres/rawparser has no decoder yet
+// (e.g. ARW, RAF, DNG, ORF) don't have a subpackage here; add one once
+// rawparser exposes a NewXxxParser for that format to wrap.
+package parsers
+
+import "github.com/jeremytorres/rawparser"
+
+// Factory creates a new rawparser.RawParser for the given host byte order.
+type Factory func(littleEndian bool) rawparser.RawParser
+
+var factories = make(map[string]Factory)
+
+// Register associates key (e.g. rawparser.NefParserKey) with factory so that
+// New and Keys can discover it. Register panics if key is already
+// registered, since that indicates two parser packages were blank-imported
+// for the same RAW format.
+func Register(key string, factory Factory) {
+	if _, exists := factories[key]; exists {
+		panic("parsers: Register called twice for key " + key)
+	}
+	factories[key] = factory
+}
+
+// Keys returns the keys of all currently registered parsers.
+func Keys() []string {
+	keys := make([]string, 0, len(factories))
+	for key := range factories {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// IsRegistered reports whether key has a registered parser.
+func IsRegistered(key string) bool {
+	_, ok := factories[key]
+	return ok
+}
+
+// New returns a new parser instance for key, or nil if key is not
+// registered.
+func New(key string, littleEndian bool) rawparser.RawParser {
+	factory, ok := factories[key]
+	if !ok {
+		return nil
+	}
+	return factory(littleEndian)
+}
@@ -0,0 +1,95 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package parsers
+
+import (
+	"testing"
+
+	"github.com/jeremytorres/rawparser"
+)
+
+func resetFactories() {
+	factories = make(map[string]Factory)
+}
+
+func TestRegisterAndNew(t *testing.T) {
+	defer resetFactories()
+	resetFactories()
+
+	var gotLittleEndian bool
+	Register("TST", func(littleEndian bool) rawparser.RawParser {
+		gotLittleEndian = littleEndian
+		return nil
+	})
+
+	if !IsRegistered("TST") {
+		t.Error("IsRegistered(\"TST\") = false, want true after Register")
+	}
+	if IsRegistered("NOPE") {
+		t.Error("IsRegistered(\"NOPE\") = true, want false")
+	}
+
+	New("TST", true)
+	if !gotLittleEndian {
+		t.Error("New did not forward littleEndian to the factory")
+	}
+
+	if New("NOPE", false) != nil {
+		t.Error("New for an unregistered key should return nil")
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	defer resetFactories()
+	resetFactories()
+
+	Register("TST", func(bool) rawparser.RawParser { return nil })
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register called twice for the same key should panic")
+		}
+	}()
+	Register("TST", func(bool) rawparser.RawParser { return nil })
+}
+
+func TestKeys(t *testing.T) {
+	defer resetFactories()
+	resetFactories()
+
+	Register("AAA", func(bool) rawparser.RawParser { return nil })
+	Register("BBB", func(bool) rawparser.RawParser { return nil })
+
+	keys := Keys()
+	if len(keys) != 2 {
+		t.Fatalf("Keys() = %v, want 2 entries", keys)
+	}
+	seen := map[string]bool{}
+	for _, k := range keys {
+		seen[k] = true
+	}
+	if !seen["AAA"] || !seen["BBB"] {
+		t.Errorf("Keys() = %v, want to contain AAA and BBB", keys)
+	}
+}
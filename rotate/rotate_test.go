@@ -0,0 +1,108 @@
+/*
+ Copyright (c) 2013 Jeremy Torres, https://github.com/jeremytorres/jpegextract
+
+ Permission is hereby granted, free of charge, to any person obtaining
+ a copy of this software and associated documentation files (the
+ "Software"), to deal in the Software without restriction, including
+ without limitation the rights to use, copy, modify, merge, publish,
+ distribute, sublicense, and/or sell copies of the Software, and to
+ permit persons to whom the Software is furnished to do so, subject to
+ the following conditions:
+
+ The above copyright notice and this permission notice shall be
+ included in all copies or substantial portions of the Software.
+
+ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package rotate
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// newTestImage builds a 2x1 image where the left pixel is red and the
+// right pixel is blue, so rotation/mirroring can be checked by pixel
+// position rather than just by the resulting bounds.
+func newTestImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 0, color.RGBA{B: 255, A: 255})
+	return img
+}
+
+func TestOrientationConstantsMatchExifSpec(t *testing.T) {
+	// Per the TIFF/EXIF Orientation tag, 6 means "rotate 90 CW" and 8 means
+	// "rotate 270 CW" -- the opposite of what these constants once held.
+	if Rotate90 != 6 {
+		t.Errorf("Rotate90 = %d, want 6 (EXIF tag value)", Rotate90)
+	}
+	if Rotate270 != 8 {
+		t.Errorf("Rotate270 = %d, want 8 (EXIF tag value)", Rotate270)
+	}
+}
+
+func TestApplyOrientationRotate90(t *testing.T) {
+	src := newTestImage()
+	dst := applyOrientation(src, Rotate90)
+
+	b := dst.Bounds()
+	if b.Dx() != 1 || b.Dy() != 2 {
+		t.Fatalf("Rotate90 bounds = %v, want 1x2", b)
+	}
+	// A 90 CW rotation of [red, blue] (left-to-right) becomes [red, blue]
+	// top-to-bottom.
+	if r, _, _, _ := dst.At(0, 0).RGBA(); r == 0 {
+		t.Error("Rotate90: expected red pixel at top")
+	}
+	if _, _, bl, _ := dst.At(0, 1).RGBA(); bl == 0 {
+		t.Error("Rotate90: expected blue pixel at bottom")
+	}
+}
+
+func TestApplyOrientationRotate270(t *testing.T) {
+	src := newTestImage()
+	dst := applyOrientation(src, Rotate270)
+
+	b := dst.Bounds()
+	if b.Dx() != 1 || b.Dy() != 2 {
+		t.Fatalf("Rotate270 bounds = %v, want 1x2", b)
+	}
+	// A 270 CW (90 CCW) rotation of [red, blue] left-to-right becomes
+	// [blue, red] top-to-bottom.
+	if _, _, bl, _ := dst.At(0, 0).RGBA(); bl == 0 {
+		t.Error("Rotate270: expected blue pixel at top")
+	}
+	if r, _, _, _ := dst.At(0, 1).RGBA(); r == 0 {
+		t.Error("Rotate270: expected red pixel at bottom")
+	}
+}
+
+func TestOrientationFromRadians(t *testing.T) {
+	tests := []struct {
+		degrees float64
+		want    int
+	}{
+		{0, Normal},
+		{90, Rotate90},
+		{180, Rotate180},
+		{270, Rotate270},
+		{-90, Rotate270},
+		{360, Normal},
+	}
+	for _, tt := range tests {
+		got := OrientationFromRadians(tt.degrees * math.Pi / 180)
+		if got != tt.want {
+			t.Errorf("OrientationFromRadians(%g deg) = %d, want %d", tt.degrees, got, tt.want)
+		}
+	}
+}